@@ -0,0 +1,155 @@
+// Package acquisition defines the pluggable event acquisition sources used
+// by whids. An Acquisition is anything able to produce GoEvtxMap events,
+// whether it reads them live from a Windows event channel, replays them
+// from saved EVTX files or receives them over the network from a syslog
+// forwarder.
+package acquisition
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+
+	"metrics"
+)
+
+// Mode tells the main loop how a source behaves so it can be waited on
+// (or timed out) appropriately.
+type Mode int
+
+const (
+	// ModeTail sources run until cancelled (live channel listeners,
+	// network listeners, ...).
+	ModeTail Mode = iota
+	// ModeOneShot sources produce a finite set of events and terminate
+	// on their own once exhausted (e.g. replaying a directory of EVTX
+	// files).
+	ModeOneShot
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeOneShot:
+		return "one-shot"
+	default:
+		return "tail"
+	}
+}
+
+// Acquisition is the interface every event source must implement. Configure
+// is called once with a source specific configuration, Run is expected to
+// push events onto out until ctx is cancelled (Tail sources) or until it has
+// nothing left to produce (OneShot sources).
+type Acquisition interface {
+	// Configure prepares the source from a set of key/value options, as
+	// built from CLI flags.
+	Configure(conf map[string]interface{}) error
+	// Run starts producing events on out. It must return when ctx is
+	// cancelled or when the source is exhausted.
+	Run(ctx context.Context, out chan<- *evtx.GoEvtxMap) error
+	// Name returns a unique, human readable identifier for the source,
+	// used in logs and per-source counters.
+	Name() string
+	// Mode reports whether the source tails forever or terminates once
+	// done replaying.
+	Mode() Mode
+}
+
+// Registry keeps track of the sources enabled for a run and fans their
+// events into a single aggregated channel, the same way the previous
+// per-channel goroutines in main fed a shared signals/events pipeline.
+type Registry struct {
+	sources []Acquisition
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{sources: make([]Acquisition, 0)}
+}
+
+// Add registers a configured source.
+func (r *Registry) Add(a Acquisition) {
+	r.sources = append(r.sources, a)
+}
+
+// Sources returns the sources currently registered.
+func (r *Registry) Sources() []Acquisition {
+	return r.sources
+}
+
+// TaggedEvent wraps an event with the name of the source that produced it,
+// so callers can keep per-source counters without threading extra state
+// through the engine match loop.
+type TaggedEvent struct {
+	Source string
+	Event  *evtx.GoEvtxMap
+}
+
+// RunAllTagged behaves like RunAll but tags every event with the name of
+// the source that emitted it, so the main loop can keep per-source
+// EPS/alert counters.
+func (r *Registry) RunAllTagged(ctx context.Context) (events chan *TaggedEvent, errc chan error) {
+	events = make(chan *TaggedEvent)
+	errc = make(chan error, len(r.sources))
+	wg := sync.WaitGroup{}
+
+	for _, src := range r.sources {
+		src := src
+		raw := make(chan *evtx.GoEvtxMap)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			defer close(raw)
+			defer metrics.AcquisitionUp.WithLabelValues(src.Name()).Set(0)
+			if err := src.Run(ctx, raw); err != nil {
+				errc <- fmt.Errorf("acquisition %s: %w", src.Name(), err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for event := range raw {
+				select {
+				case events <- &TaggedEvent{Source: src.Name(), Event: event}:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	return
+}
+
+// RunAll starts every registered source in its own goroutine and aggregates
+// their events onto a single channel. The returned channel is closed once
+// every source has returned. Errors are reported individually through
+// errc, which is never closed so callers should select on ctx.Done() too.
+func (r *Registry) RunAll(ctx context.Context) (events chan *evtx.GoEvtxMap, errc chan error) {
+	events = make(chan *evtx.GoEvtxMap)
+	errc = make(chan error, len(r.sources))
+	wg := sync.WaitGroup{}
+
+	for _, src := range r.sources {
+		src := src
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := src.Run(ctx, events); err != nil {
+				errc <- fmt.Errorf("acquisition %s: %w", src.Name(), err)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	return
+}
@@ -0,0 +1,241 @@
+package acquisition
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// rfc3164Header matches the "<PRI>MMM DD HH:MM:SS HOST TAG: " prefix that
+// forwarders such as NXLog or winlogbeat prepend to the JSON payload of a
+// forwarded Windows event.
+var rfc3164Header = regexp.MustCompile(`^<\d+>\w+\s+\d+\s+[\d:]+\s+\S+\s+\S+:\s*`)
+
+// rfc5424Header matches the "<PRI>VERSION TIMESTAMP HOST APP PROCID MSGID
+// STRUCTURED-DATA " prefix of a syslog message formatted per RFC 5424, where
+// STRUCTURED-DATA is either "-" or one or more [...] elements.
+var rfc5424Header = regexp.MustCompile(`^<\d+>\d+\s+\S+\s+\S+\s+\S+\s+\S+\s+\S+\s+(?:-|(?:\[(?:[^\]\\]|\\.)*\])+)\s*`)
+
+// SyslogSource listens for forwarded Windows events wrapped in RFC3164 or
+// RFC5424 syslog frames, as produced by NXLog or winlogbeat, and extracts
+// their JSON payload.
+type SyslogSource struct {
+	proto   string
+	addr    string
+	ln      net.Listener
+	pktConn net.PacketConn
+}
+
+// NewSyslogSource creates a source listening on addr ("host:port") using
+// proto ("udp" or "tcp").
+func NewSyslogSource(proto, addr string) *SyslogSource {
+	return &SyslogSource{proto: proto, addr: addr}
+}
+
+// Configure accepts "proto" and "addr" keys overriding the constructor
+// arguments.
+func (s *SyslogSource) Configure(conf map[string]interface{}) error {
+	if p, ok := conf["proto"].(string); ok && p != "" {
+		s.proto = p
+	}
+	if a, ok := conf["addr"].(string); ok && a != "" {
+		s.addr = a
+	}
+	if s.proto == "" {
+		s.proto = "udp"
+	}
+	if s.addr == "" {
+		return fmt.Errorf("syslog source requires a listen address")
+	}
+	return nil
+}
+
+// Name returns the protocol and address this source listens on.
+func (s *SyslogSource) Name() string {
+	return fmt.Sprintf("syslog:%s://%s", s.proto, s.addr)
+}
+
+// Mode reports that a syslog listener tails forever until cancelled.
+func (s *SyslogSource) Mode() Mode {
+	return ModeTail
+}
+
+// Run starts the UDP or TCP listener and parses every frame received until
+// ctx is cancelled.
+func (s *SyslogSource) Run(ctx context.Context, out chan<- *evtx.GoEvtxMap) error {
+	switch s.proto {
+	case "udp":
+		return s.runUDP(ctx, out)
+	case "tcp":
+		return s.runTCP(ctx, out)
+	default:
+		return fmt.Errorf("unsupported syslog protocol: %s", s.proto)
+	}
+}
+
+func (s *SyslogSource) runUDP(ctx context.Context, out chan<- *evtx.GoEvtxMap) error {
+	conn, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.pktConn = conn
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+				return err
+			}
+		}
+		s.parseFrame(buf[:n], out, ctx)
+	}
+}
+
+func (s *SyslogSource) runTCP(ctx context.Context, out chan<- *evtx.GoEvtxMap) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+				return err
+			}
+		}
+		go s.handleTCPConn(ctx, conn, out)
+	}
+}
+
+func (s *SyslogSource) handleTCPConn(ctx context.Context, conn net.Conn, out chan<- *evtx.GoEvtxMap) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		s.parseFrame(scanner.Bytes(), out, ctx)
+	}
+}
+
+// systemFields lists the NXLog/winlogbeat flat-JSON keys that belong under
+// /Event/System rather than /Event/EventData, along with the System field
+// name gene rules actually match on.
+var systemFields = map[string]string{
+	"EventID":           "EventID",
+	"EventRecordID":     "EventRecordID",
+	"Channel":           "Channel",
+	"Computer":          "Computer",
+	"Hostname":          "Computer",
+	"SourceName":        "Provider",
+	"ProviderName":      "Provider",
+	"Severity":          "Level",
+	"SeverityValue":     "Level",
+	"ProcessID":         "ProcessID",
+	"ThreadID":          "ThreadID",
+	"Opcode":            "Opcode",
+	"Task":              "Task",
+	"Keywords":          "Keywords",
+	"Version":           "Version",
+	"EventTime":         "TimeCreated",
+	"EventReceivedTime": "TimeCreated",
+}
+
+// toNestedEvent maps the flat JSON object a forwarder such as NXLog or
+// winlogbeat emits for a Windows event into the nested
+// /Event/System + /Event/EventData shape gene rules match against, which is
+// the same shape evtx.GoEvtxMap uses for locally parsed .evtx records.
+//
+// A forwarder that already emits a nested "EventData" object (NXLog's
+// xm_json with msvistalog, configured to preserve EventData as a map) is
+// passed through unchanged for that field; one that flattens EventData's
+// children to the top level (winlogbeat's default) has every remaining,
+// unrecognized key folded into EventData instead.
+func toNestedEvent(flat map[string]interface{}) evtx.GoEvtxMap {
+	system := make(map[string]interface{})
+	eventData := make(map[string]interface{})
+
+	for key, val := range flat {
+		if key == "EventData" {
+			if nested, ok := val.(map[string]interface{}); ok {
+				for k, v := range nested {
+					eventData[k] = v
+				}
+				continue
+			}
+		}
+		if sysKey, ok := systemFields[key]; ok {
+			system[sysKey] = val
+			continue
+		}
+		eventData[key] = val
+	}
+
+	ge := make(evtx.GoEvtxMap)
+	ge["Event"] = map[string]interface{}{
+		"System":    system,
+		"EventData": eventData,
+	}
+	return ge
+}
+
+// parseFrame strips the syslog header (RFC3164 or RFC5424) from a frame,
+// unmarshals what remains as a forwarded Windows event and maps it into the
+// nested shape gene rules expect.
+func (s *SyslogSource) parseFrame(frame []byte, out chan<- *evtx.GoEvtxMap, ctx context.Context) {
+	payload := strings.TrimSpace(string(frame))
+	switch {
+	case rfc5424Header.MatchString(payload):
+		payload = rfc5424Header.ReplaceAllString(payload, "")
+	case rfc3164Header.MatchString(payload):
+		payload = rfc3164Header.ReplaceAllString(payload, "")
+	}
+
+	var flat map[string]interface{}
+	if err := json.Unmarshal([]byte(payload), &flat); err != nil {
+		return
+	}
+
+	// A forwarder that ships the already-nested XML-to-JSON shape (top
+	// level "Event" key) needs no remapping.
+	var ge evtx.GoEvtxMap
+	if _, ok := flat["Event"]; ok {
+		ge = make(evtx.GoEvtxMap)
+		raw, err := json.Marshal(flat)
+		if err != nil {
+			return
+		}
+		if err := json.Unmarshal(raw, &ge); err != nil {
+			return
+		}
+	} else {
+		ge = toNestedEvent(flat)
+	}
+
+	select {
+	case out <- &ge:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,99 @@
+package acquisition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+	"github.com/0xrawsec/golang-win32/win32/wevtapi"
+)
+
+// ChannelAliases maps friendly names to the actual Windows event channel
+// they refer to, so operators don't have to remember the full path.
+var ChannelAliases = map[string]string{
+	"sysmon":   "Microsoft-Windows-Sysmon/Operational",
+	"security": "Security",
+}
+
+// WevtapiSource is the historical live acquisition mode: it subscribes to a
+// Windows event channel via wevtapi and streams future events as they are
+// logged.
+type WevtapiSource struct {
+	channel string
+	signals chan bool
+}
+
+// NewWevtapiSource creates a source bound to the given Windows channel name
+// or alias.
+func NewWevtapiSource(channel string) *WevtapiSource {
+	return &WevtapiSource{channel: channel}
+}
+
+// Configure accepts a "channel" key overriding the channel passed at
+// construction time.
+func (w *WevtapiSource) Configure(conf map[string]interface{}) error {
+	if c, ok := conf["channel"].(string); ok && c != "" {
+		w.channel = c
+	}
+	if w.channel == "" {
+		return fmt.Errorf("wevtapi source requires a channel")
+	}
+	if alias, ok := ChannelAliases[strings.ToLower(w.channel)]; ok {
+		w.channel = alias
+	}
+	w.signals = make(chan bool)
+	return nil
+}
+
+// Name returns "wevtapi:<channel>" for the resolved Windows channel this
+// source listens on.
+func (w *WevtapiSource) Name() string {
+	return fmt.Sprintf("wevtapi:%s", w.channel)
+}
+
+// Mode reports that wevtapi subscriptions tail forever until cancelled.
+func (w *WevtapiSource) Mode() Mode {
+	return ModeTail
+}
+
+// Run subscribes to future events on the configured channel and converts
+// them to GoEvtxMap until ctx is cancelled.
+func (w *WevtapiSource) Run(ctx context.Context, out chan<- *evtx.GoEvtxMap) error {
+	ec := wevtapi.GetAllEventsFromChannel(w.channel, wevtapi.EvtSubscribeToFutureEvents, w.signals)
+
+	go func() {
+		<-ctx.Done()
+		close(w.signals)
+	}()
+
+	for xe := range ec {
+		event, err := xmlEventToGoEvtxMap(xe)
+		if err != nil {
+			continue
+		}
+		select {
+		case out <- event:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// xmlEventToGoEvtxMap converts an XMLEvent as returned by wevtapi to a
+// GoEvtxMap object that Gene can use.
+// TODO: Improve for more perf
+func xmlEventToGoEvtxMap(xe *wevtapi.XMLEvent) (*evtx.GoEvtxMap, error) {
+	ge := make(evtx.GoEvtxMap)
+	bytes, err := json.Marshal(xe.ToJSONEvent())
+	if err != nil {
+		return &ge, err
+	}
+	err = json.Unmarshal(bytes, &ge)
+	if err != nil {
+		return &ge, err
+	}
+	return &ge, nil
+}
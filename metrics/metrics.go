@@ -0,0 +1,63 @@
+// Package metrics declares the Prometheus metrics whids exposes when
+// started with -metrics-addr, plus the /healthz and /rules HTTP endpoints
+// that make a running instance observable when deployed as a Windows
+// service across a fleet.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// EventsTotal counts events scanned, labeled by the acquisition
+	// source that produced them.
+	EventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whids_events_total",
+		Help: "Total number of events scanned, per acquisition source.",
+	}, []string{"channel"})
+
+	// AlertsTotal counts alerts raised, labeled by rule name and
+	// criticality.
+	AlertsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whids_alerts_total",
+		Help: "Total number of alerts raised, per rule and criticality.",
+	}, []string{"rule", "criticality"})
+
+	// MatchDuration times calls to the gene engine's Match method.
+	MatchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "whids_match_duration_seconds",
+		Help:    "Time spent matching a single event against the loaded rules.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// RuleLoadErrors counts rule files that failed to load.
+	RuleLoadErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "whids_rule_load_errors_total",
+		Help: "Total number of rule files that failed to load.",
+	})
+
+	// AcquisitionUp reports whether an acquisition source is currently
+	// running (1) or stopped (0).
+	AcquisitionUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "whids_acquisition_up",
+		Help: "Whether an acquisition source is currently running.",
+	}, []string{"source"})
+
+	// SinkDropped counts alerts dropped because a sink's buffer was
+	// full.
+	SinkDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whids_sink_dropped_total",
+		Help: "Total number of alerts dropped per sink because its buffer was full.",
+	}, []string{"sink"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		EventsTotal,
+		AlertsTotal,
+		MatchDuration,
+		RuleLoadErrors,
+		AcquisitionUp,
+		SinkDropped,
+	)
+}
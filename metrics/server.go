@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// RuleInfo describes a loaded rule for the /rules endpoint.
+type RuleInfo struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// NewServer builds the HTTP handler exposing /metrics (Prometheus format,
+// including Go runtime metrics via promhttp), /healthz (plain liveness
+// check) and /rules (loaded rule names and hashes, as provided by
+// rules()).
+func NewServer(addr string, rules func() []RuleInfo) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	mux.HandleFunc("/rules", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rules())
+	})
+
+	return &http.Server{Addr: addr, Handler: mux}
+}
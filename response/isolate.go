@@ -0,0 +1,39 @@
+package response
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// IsolateHost cuts the host off from the network by pushing a Windows
+// Firewall rule blocking all inbound and outbound traffic, leaving only
+// the rule itself to be removed by an operator to restore connectivity.
+type IsolateHost struct{}
+
+// Name returns "isolate".
+func (IsolateHost) Name() string {
+	return "isolate"
+}
+
+const isolationRuleName = "whids-isolation"
+
+// Run pushes the blocking netsh advfirewall rules. Running it again while
+// the host is already isolated adds another pair of identically named
+// blocking rules, which leaves the host in the same isolated state.
+func (IsolateHost) Run(ctx Context) error {
+	cmd := exec.Command("netsh", "advfirewall", "firewall", "add", "rule",
+		fmt.Sprintf("name=%s", isolationRuleName),
+		"dir=out", "action=block", "enable=yes")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("isolate: netsh failed: %w (%s)", err, out)
+	}
+
+	cmd = exec.Command("netsh", "advfirewall", "firewall", "add", "rule",
+		fmt.Sprintf("name=%s", isolationRuleName),
+		"dir=in", "action=block", "enable=yes")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("isolate: netsh failed: %w (%s)", err, out)
+	}
+
+	return nil
+}
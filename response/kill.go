@@ -0,0 +1,28 @@
+package response
+
+import (
+	"fmt"
+	"os"
+)
+
+// KillProcess terminates the process that raised the matched event.
+type KillProcess struct{}
+
+// Name returns "kill".
+func (KillProcess) Name() string {
+	return "kill"
+}
+
+// Run terminates ctx.PID.
+func (KillProcess) Run(ctx Context) error {
+	if ctx.PID <= 0 {
+		return fmt.Errorf("kill: no PID to act on")
+	}
+
+	proc, err := os.FindProcess(ctx.PID)
+	if err != nil {
+		return fmt.Errorf("kill: failed to find pid %d: %w", ctx.PID, err)
+	}
+
+	return proc.Kill()
+}
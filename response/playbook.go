@@ -0,0 +1,51 @@
+package response
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// Playbook runs a user-supplied PowerShell script or executable, feeding it
+// the matched event as JSON on stdin. It is the escape hatch for reactions
+// not built into whids itself.
+type Playbook struct {
+	// Path to the script/executable to run. PowerShell scripts (.ps1)
+	// are invoked through powershell.exe -File.
+	Path string
+	// Args are extra arguments passed to the playbook after the script
+	// path.
+	Args []string
+}
+
+// NewPlaybook creates a Playbook running path with the given extra args.
+func NewPlaybook(path string, args ...string) *Playbook {
+	return &Playbook{Path: path, Args: args}
+}
+
+// Name returns "playbook:<path>".
+func (p *Playbook) Name() string {
+	return fmt.Sprintf("playbook:%s", p.Path)
+}
+
+// Run executes the playbook with the matched event JSON on stdin.
+func (p *Playbook) Run(ctx Context) error {
+	var cmd *exec.Cmd
+	if strings.HasSuffix(strings.ToLower(p.Path), ".ps1") {
+		args := append([]string{"-NoProfile", "-NonInteractive", "-File", p.Path}, p.Args...)
+		cmd = exec.Command("powershell.exe", args...)
+	} else {
+		cmd = exec.Command(p.Path, p.Args...)
+	}
+
+	cmd.Stdin = bytes.NewReader(evtx.ToJSON(ctx.Event))
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("playbook %s failed: %w (%s)", p.Path, err, out)
+	}
+	return nil
+}
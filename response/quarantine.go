@@ -0,0 +1,50 @@
+package response
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/0xrawsec/golang-utils/fsutil"
+)
+
+// quarantineDir is where quarantined binaries are moved to. It must live
+// on the same volume as the images it quarantines so the move is atomic.
+const quarantineDir = `C:\Windows\whids-quarantine`
+
+// Quarantine moves the offending image to a protected directory and
+// strips its ACLs so it can no longer be executed.
+type Quarantine struct{}
+
+// Name returns "quarantine".
+func (Quarantine) Name() string {
+	return "quarantine"
+}
+
+// Run moves ctx.Image into quarantineDir and strips its inherited ACLs via
+// icacls.
+func (Quarantine) Run(ctx Context) error {
+	if ctx.Image == "" {
+		return fmt.Errorf("quarantine: no image path to act on")
+	}
+	if !fsutil.IsFile(ctx.Image) {
+		return fmt.Errorf("quarantine: %s does not exist", ctx.Image)
+	}
+
+	if err := os.MkdirAll(quarantineDir, 0700); err != nil {
+		return fmt.Errorf("quarantine: failed to create %s: %w", quarantineDir, err)
+	}
+
+	dest := filepath.Join(quarantineDir, filepath.Base(ctx.Image))
+	if err := os.Rename(ctx.Image, dest); err != nil {
+		return fmt.Errorf("quarantine: failed to move %s: %w", ctx.Image, err)
+	}
+
+	cmd := exec.Command("icacls", dest, "/inheritance:r", "/grant:r", "SYSTEM:(F)")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("quarantine: icacls failed on %s: %w (%s)", dest, err, out)
+	}
+
+	return nil
+}
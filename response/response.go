@@ -0,0 +1,242 @@
+// Package response implements whids' active response subsystem: when a
+// rule tagged with a `response:` annotation fires above the configured
+// criticality threshold, the matching Reactions (kill, suspend, isolate,
+// quarantine, playbook, ...) are executed against the offending process.
+// It turns whids from a passive IDS into an optional IPS.
+package response
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+	"github.com/0xrawsec/golang-utils/log"
+
+	"sink"
+)
+
+// pidPath and imagePath are the fields reactions use to locate the
+// offending process in a matched event, following the same
+// EventData.ProcessId / Sysmon Image convention gene rules already match
+// on.
+var (
+	pidPath   = evtx.Path("/Event/EventData/ProcessId")
+	imagePath = evtx.Path("/Event/EventData/Image")
+)
+
+// Context carries everything a Reaction needs to act on a match: the
+// triggering event, the rule that fired and the process it points at.
+type Context struct {
+	Event       *evtx.GoEvtxMap
+	Rule        string
+	Criticality int
+	PID         int
+	Image       string
+}
+
+// Reaction is a single response action (terminate a process, isolate the
+// host, ...). Implementations must be safe to call concurrently for
+// different Contexts.
+type Reaction interface {
+	// Name identifies the reaction in a response: annotation and in the
+	// synthetic audit alert.
+	Name() string
+	// Run executes the reaction against ctx, returning an error if the
+	// action could not be carried out.
+	Run(ctx Context) error
+}
+
+// responseWorkers is the number of goroutines running reactions
+// concurrently. Reactions shell out to external commands (PowerShell,
+// netsh, icacls, ...) that can take a while to return; running them here
+// rather than inline in the event-matching loop keeps a slow reaction from
+// stalling detection of everything else.
+const responseWorkers = 4
+
+// jobQueueSize bounds the number of reactions queued for a worker, so a
+// sustained burst of matches can't grow memory without bound; once full,
+// further reactions are dropped (and counted) rather than blocking the
+// matching loop.
+const jobQueueSize = 256
+
+// reactionJob is one reaction queued to run on a worker goroutine.
+type reactionJob struct {
+	reaction Reaction
+	ctx      Context
+}
+
+// Dispatcher gates, deduplicates and executes reactions for matched
+// events, emitting a synthetic alert through the alert-sink pipeline for
+// every reaction it runs so responses are auditable the same way regular
+// detections are.
+type Dispatcher struct {
+	enabled   bool
+	threshold int
+	sinks     *sink.Dispatcher
+	reactions map[string]Reaction
+	policy    map[string][]string // rule name -> reaction names
+	dedup     *ttlCache
+	jobs      chan reactionJob
+	dropped   uint64
+}
+
+// NewDispatcher creates a response Dispatcher and starts its worker pool.
+// enabled mirrors --enable-response: when false, Dispatch is a no-op
+// regardless of policy. sinks receives a synthetic alert for every
+// reaction actually executed.
+func NewDispatcher(enabled bool, threshold int, sinks *sink.Dispatcher) *Dispatcher {
+	d := &Dispatcher{
+		enabled:   enabled,
+		threshold: threshold,
+		sinks:     sinks,
+		reactions: make(map[string]Reaction),
+		policy:    make(map[string][]string),
+		dedup:     newTTLCache(5 * time.Minute),
+		jobs:      make(chan reactionJob, jobQueueSize),
+	}
+	for i := 0; i < responseWorkers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// work runs queued reactions one at a time until the jobs channel is
+// closed. Multiple workers run concurrently, but each reaction runs to
+// completion before its worker picks up the next job.
+func (d *Dispatcher) work() {
+	for job := range d.jobs {
+		d.run(job.reaction, job.ctx)
+	}
+}
+
+// Register makes a Reaction available to be referenced by name from a
+// rule's response: annotation.
+func (d *Dispatcher) Register(r Reaction) {
+	d.reactions[r.Name()] = r
+}
+
+// SetPolicy installs the rule-name -> reaction-names mapping. whids loads
+// it from the -response-policy JSON file; the loaded rules themselves are
+// not parsed for response: annotations.
+func (d *Dispatcher) SetPolicy(policy map[string][]string) {
+	d.policy = policy
+}
+
+// Dispatch runs every reaction configured for the rules in matchedRules,
+// provided response is enabled, criticality clears the threshold and the
+// PID/rule pair hasn't already been actioned within the dedup TTL.
+func (d *Dispatcher) Dispatch(event *evtx.GoEvtxMap, matchedRules []string, criticality int) {
+	if !d.enabled || criticality < d.threshold {
+		return
+	}
+
+	// ProcessId arrives as a JSON string in Sysmon events, not a number,
+	// so it must be read with GetString and parsed rather than GetInt
+	// (which only succeeds against a genuine JSON number).
+	pidStr, _ := event.GetString(&pidPath)
+	pid, _ := strconv.Atoi(pidStr)
+	image, _ := event.GetString(&imagePath)
+
+	for _, rule := range matchedRules {
+		names, ok := d.policy[rule]
+		if !ok {
+			continue
+		}
+		for _, name := range names {
+			reaction, ok := d.reactions[name]
+			if !ok {
+				log.Errorf("response: unknown reaction %q referenced by rule %q", name, rule)
+				continue
+			}
+
+			key := fmt.Sprintf("%d:%s:%s", pid, rule, name)
+			if !d.dedup.claim(key) {
+				continue
+			}
+
+			ctx := Context{Event: event, Rule: rule, Criticality: criticality, PID: pid, Image: image}
+			select {
+			case d.jobs <- reactionJob{reaction: reaction, ctx: ctx}:
+			default:
+				// Not actioned: release the dedup key so a later match can
+				// still trigger this reaction within the TTL.
+				d.dedup.mu.Lock()
+				delete(d.dedup.m, key)
+				d.dedup.mu.Unlock()
+				d.dropped++
+				log.Errorf("response: worker pool saturated, dropped reaction %s for rule %s (pid %d), %d dropped total", name, rule, pid, d.dropped)
+			}
+		}
+	}
+}
+
+// run executes reaction and emits a synthetic audit alert reporting its
+// outcome through the sink pipeline.
+func (d *Dispatcher) run(reaction Reaction, ctx Context) {
+	err := reaction.Run(ctx)
+	if err != nil {
+		log.Errorf("response: reaction %s failed for rule %s (pid %d): %s", reaction.Name(), ctx.Rule, ctx.PID, err)
+	}
+
+	audit := make(evtx.GoEvtxMap)
+	audit["Response"] = map[string]interface{}{
+		"Reaction": reaction.Name(),
+		"Rule":     ctx.Rule,
+		"PID":      ctx.PID,
+		"Image":    ctx.Image,
+		"Success":  err == nil,
+	}
+	if err != nil {
+		audit["Response"].(map[string]interface{})["Error"] = err.Error()
+	}
+
+	d.sinks.Emit(&audit, []string{"response", reaction.Name(), ctx.Rule})
+}
+
+// ttlCache remembers keys for a fixed duration so response storms (the
+// same rule firing repeatedly for the same process) only trigger one
+// reaction per TTL window.
+type ttlCache struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	m   map[string]time.Time
+}
+
+func newTTLCache(ttl time.Duration) *ttlCache {
+	c := &ttlCache{ttl: ttl, m: make(map[string]time.Time)}
+	go c.evictExpired()
+	return c
+}
+
+// evictExpired periodically purges keys whose TTL has elapsed so a
+// long-running whids doesn't accumulate one map entry per distinct
+// (pid, rule, reaction) ever dispatched.
+func (c *ttlCache) evictExpired() {
+	ticker := time.NewTicker(c.ttl)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		c.mu.Lock()
+		for key, exp := range c.m {
+			if now.After(exp) {
+				delete(c.m, key)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// claim reports whether key is not currently in the cache, inserting it
+// (or refreshing its expiry) as a side effect.
+func (c *ttlCache) claim(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if exp, ok := c.m[key]; ok && now.Before(exp) {
+		return false
+	}
+	c.m[key] = now.Add(c.ttl)
+	return true
+}
@@ -0,0 +1,44 @@
+package response
+
+import (
+	"fmt"
+	"syscall"
+)
+
+var (
+	ntdll             = syscall.NewLazyDLL("ntdll.dll")
+	procNtSuspendProc = ntdll.NewProc("NtSuspendProcess")
+)
+
+const processSuspendResume = 0x0800
+
+// SuspendTree suspends the offending process. Sysmon/Windows do not expose
+// child enumeration in the matched event itself, so only the offending PID
+// is suspended; its child processes keep running.
+type SuspendTree struct{}
+
+// Name returns "suspend".
+func (SuspendTree) Name() string {
+	return "suspend"
+}
+
+// Run suspends ctx.PID via the undocumented NtSuspendProcess API, the
+// standard mechanism Windows itself uses to implement Process Explorer's
+// "Suspend" action.
+func (SuspendTree) Run(ctx Context) error {
+	if ctx.PID <= 0 {
+		return fmt.Errorf("suspend: no PID to act on")
+	}
+
+	handle, err := syscall.OpenProcess(processSuspendResume, false, uint32(ctx.PID))
+	if err != nil {
+		return fmt.Errorf("suspend: failed to open pid %d: %w", ctx.PID, err)
+	}
+	defer syscall.CloseHandle(handle)
+
+	ret, _, err := procNtSuspendProc.Call(uintptr(handle))
+	if ret != 0 {
+		return fmt.Errorf("suspend: NtSuspendProcess failed for pid %d: %w", ctx.PID, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Package rulebundle implements whids' signed, versioned rule bundle
+// format: a .tar.gz of gene rules plus a manifest.json (listing each
+// rule's sha256) and a detached Ed25519 signature over that manifest.
+// Bundles are only installed once both the signature and the hash of every
+// rule the manifest lists have been verified. Files in the archive that
+// the manifest does not list are not checked and are installed as they are.
+package rulebundle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestName and SignatureName are the well known suffixes of the
+// manifest and signature files published next to a bundle archive (see
+// bundleArchiveExt).
+const (
+	ManifestName  = "manifest.json"
+	SignatureName = "manifest.sig"
+)
+
+// RuleEntry describes a single rule file tracked by a bundle manifest.
+type RuleEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest is the signed description of a rule bundle's content.
+type Manifest struct {
+	Version   string      `json:"version"`
+	CreatedAt time.Time   `json:"created_at"`
+	Rules     []RuleEntry `json:"rules"`
+}
+
+// ParseManifest decodes a manifest.json document.
+func ParseManifest(raw []byte) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.Unmarshal(raw, m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return m, nil
+}
+
+// LoadManifest reads and parses the manifest.json at path.
+func LoadManifest(path string) (*Manifest, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(raw)
+}
+
+// VerifyFiles checks that every rule listed in m exists under root and
+// hashes to the sha256 recorded in the manifest. Files under root that m
+// does not list are neither checked nor rejected.
+func (m *Manifest) VerifyFiles(root string) error {
+	for _, entry := range m.Rules {
+		sum, err := sha256File(filepath.Join(root, entry.Path))
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", entry.Path, err)
+		}
+		if sum != entry.SHA256 {
+			return fmt.Errorf("rule %s: sha256 mismatch (manifest says %s, got %s)", entry.Path, entry.SHA256, sum)
+		}
+	}
+	return nil
+}
+
+// sha256File returns the lowercase hex sha256 digest of the file at path.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,29 @@
+package rulebundle
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+)
+
+// ParsePublicKeyHex decodes a hex encoded Ed25519 public key, as passed to
+// -rules-pubkey.
+func ParsePublicKeyHex(h string) (ed25519.PublicKey, error) {
+	raw, err := hex.DecodeString(h)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key hex: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key length: got %d, want %d", len(raw), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// VerifySignature checks sig is a valid Ed25519 signature of manifest
+// under pubKey.
+func VerifySignature(manifest, sig []byte, pubKey ed25519.PublicKey) error {
+	if !ed25519.Verify(pubKey, manifest, sig) {
+		return fmt.Errorf("manifest signature verification failed")
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package rulebundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// bundleArchiveExt is the suffix every published bundle archive is
+// expected to have. The manifest and its detached signature are published
+// next to the archive under the same base name, e.g. bundle.tar.gz is
+// accompanied by bundle.manifest.json and bundle.manifest.sig.
+const bundleArchiveExt = ".tar.gz"
+
+// Updater pulls, verifies and installs rule bundles published at a URL,
+// skipping the download entirely when the manifest version hasn't changed
+// since the last update.
+type Updater struct {
+	Client        *http.Client
+	URL           string
+	PubKey        ed25519.PublicKey
+	DatabasePath  string // directory the verified rules are installed into
+	StateManifest string // where the last-known manifest.json is kept
+}
+
+// NewUpdater creates an Updater pulling bundles from url, verifying them
+// against pubKey, and installing them into databasePath. The last-known
+// manifest is kept at databasePath + ".manifest.json" so a subsequent
+// Update can tell whether the bundle actually changed.
+func NewUpdater(client *http.Client, url string, pubKey ed25519.PublicKey, databasePath string) *Updater {
+	return &Updater{
+		Client:        client,
+		URL:           url,
+		PubKey:        pubKey,
+		DatabasePath:  databasePath,
+		StateManifest: databasePath + ".manifest.json",
+	}
+}
+
+// Update fetches the manifest at u.URL, and, if its version differs from
+// the last known one, downloads the whole bundle, verifies its signature
+// and per-file hashes, then atomically swaps it into u.DatabasePath.
+//
+// Bundles are published as a single tarball, so there is no way to fetch
+// only the rules that changed: every Update either does nothing (manifest
+// unchanged) or re-downloads the full archive.
+func (u *Updater) Update() error {
+	manifest, manifestBytes, err := u.fetchManifest()
+	if err != nil {
+		return fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+
+	if prev, err := LoadManifest(u.StateManifest); err == nil && prev.Version == manifest.Version {
+		return nil
+	}
+
+	// Stage on the same volume as DatabasePath: atomicSwap installs the
+	// staged directory with os.Rename, which fails with EXDEV across
+	// filesystems, and DatabasePath's parent is the one guaranteed to be
+	// writable and on the target filesystem.
+	stageParent := filepath.Dir(u.DatabasePath)
+	tmpDir, err := ioutil.TempDir(stageParent, "whids-rules-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archive, err := u.download(u.URL)
+	if err != nil {
+		return fmt.Errorf("failed to download bundle: %w", err)
+	}
+	defer os.Remove(archive)
+
+	if err := extractTarGz(archive, tmpDir); err != nil {
+		return fmt.Errorf("failed to extract bundle: %w", err)
+	}
+
+	if err := manifest.VerifyFiles(tmpDir); err != nil {
+		return fmt.Errorf("bundle integrity check failed: %w", err)
+	}
+
+	// Only now that everything has been verified do we touch the live
+	// database: rename the old one aside, move the staged one in, then
+	// remove the old one.
+	if err := atomicSwap(tmpDir, u.DatabasePath); err != nil {
+		return fmt.Errorf("failed to install verified bundle: %w", err)
+	}
+
+	ioutil.WriteFile(u.StateManifest, manifestBytes, 0644)
+
+	return nil
+}
+
+// fetchManifest downloads manifest.json and manifest.sig from alongside
+// u.URL and returns the manifest, and the raw manifest bytes (so the
+// caller can persist them to StateManifest verbatim), once its signature
+// has been verified.
+func (u *Updater) fetchManifest() (*Manifest, []byte, error) {
+	base := strings.TrimSuffix(u.URL, bundleArchiveExt)
+
+	manifestBytes, err := u.get(base + "." + ManifestName)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err := u.get(base + "." + SignatureName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := VerifySignature(manifestBytes, sig, u.PubKey); err != nil {
+		return nil, nil, err
+	}
+
+	manifest, err := ParseManifest(manifestBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifest, manifestBytes, nil
+}
+
+func (u *Updater) get(url string) ([]byte, error) {
+	resp, err := u.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %d", url, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (u *Updater) download(url string) (string, error) {
+	resp, err := u.Client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	f, err := ioutil.TempFile("", "whids-bundle-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// extractTarGz extracts a .tar.gz archive into dir, refusing any entry
+// whose name would resolve outside dir (zip-slip) or whose type isn't a
+// plain file or directory.
+func extractTarGz(archive, dir string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(absDir, hdr.Name)
+		if target != absDir && !strings.HasPrefix(target, absDir+string(os.PathSeparator)) {
+			return fmt.Errorf("refusing to extract %q: escapes %s", hdr.Name, dir)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0750); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			out.Close()
+		default:
+			return fmt.Errorf("refusing to extract %q: unsupported entry type %c", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+// atomicSwap replaces dst with the content of staged, moving any existing
+// dst aside until the new content is in place.
+func atomicSwap(staged, dst string) error {
+	backup := dst + ".old"
+	os.RemoveAll(backup)
+
+	if _, err := os.Stat(dst); err == nil {
+		if err := os.Rename(dst, backup); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(staged, dst); err != nil {
+		// Best effort rollback
+		os.Rename(backup, dst)
+		return err
+	}
+
+	os.RemoveAll(backup)
+	return nil
+}
@@ -0,0 +1,97 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// ElasticsearchSink indexes alerts into an Elasticsearch index using the
+// `_bulk` API, batching documents before each request.
+type ElasticsearchSink struct {
+	url        string
+	index      string
+	client     *http.Client
+	batchSize  int
+	docs       []*evtx.GoEvtxMap
+	maxPending int
+	dropped    uint64
+}
+
+// NewElasticsearchSink creates a sink bulk-indexing into index at the
+// given Elasticsearch base url, flushing every batchSize documents.
+func NewElasticsearchSink(url, index string, batchSize int) *ElasticsearchSink {
+	return &ElasticsearchSink{
+		url:        strings.TrimRight(url, "/"),
+		index:      index,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		batchSize:  batchSize,
+		docs:       make([]*evtx.GoEvtxMap, 0, batchSize),
+		maxPending: batchSize * maxPendingFactor,
+	}
+}
+
+// Name returns "elasticsearch:<url>/<index>".
+func (s *ElasticsearchSink) Name() string {
+	return fmt.Sprintf("elasticsearch:%s/%s", s.url, s.index)
+}
+
+// Emit buffers alert, flushing the batch once it reaches batchSize.
+func (s *ElasticsearchSink) Emit(alert *evtx.GoEvtxMap) error {
+	s.docs = append(s.docs, alert)
+	if len(s.docs) >= s.batchSize {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush sends the buffered documents to the `_bulk` endpoint.
+func (s *ElasticsearchSink) Flush() error {
+	if len(s.docs) == 0 {
+		return nil
+	}
+
+	buf := bytes.Buffer{}
+	for _, doc := range s.docs {
+		meta, _ := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": s.index}})
+		buf.Write(meta)
+		buf.WriteByte('\n')
+		buf.Write(evtx.ToJSON(doc))
+		buf.WriteByte('\n')
+	}
+
+	resp, err := s.client.Post(s.url+"/_bulk", "application/x-ndjson", &buf)
+	if err != nil {
+		return s.dropExcess(fmt.Errorf("bulk index request failed: %w", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return s.dropExcess(fmt.Errorf("bulk index request returned status %d", resp.StatusCode))
+	}
+
+	s.docs = s.docs[:0]
+	return nil
+}
+
+// dropExcess trims s.docs down to maxPending, counting and logging whatever
+// it drops, so a persistently unreachable Elasticsearch cluster can't grow
+// s.docs without bound. It always returns err, for use as `return
+// s.dropExcess(err)`.
+func (s *ElasticsearchSink) dropExcess(err error) error {
+	if excess := len(s.docs) - s.maxPending; excess > 0 {
+		s.docs = s.docs[excess:]
+		s.dropped += uint64(excess)
+		fmt.Printf("elasticsearch %s/%s: dropped %d alerts after exceeding %d pending, %d dropped total\n", s.url, s.index, excess, s.maxPending, s.dropped)
+	}
+	return err
+}
+
+// Close flushes any buffered documents.
+func (s *ElasticsearchSink) Close() error {
+	return s.Flush()
+}
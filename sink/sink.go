@@ -0,0 +1,148 @@
+// Package sink defines the pluggable alert sinks whids can dispatch
+// matched events to: stdout, a rotating JSON-lines file, syslog, an HTTP
+// webhook, Elasticsearch and Kafka. Sinks are fanned out to concurrently so
+// a slow SIEM never blocks the matching loop.
+package sink
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// flushInterval is how often a batching sink (webhook, Elasticsearch) is
+// flushed even if its batch hasn't filled up, so a low-volume deployment
+// still sees alerts delivered promptly instead of only at shutdown.
+const flushInterval = 10 * time.Second
+
+// Sink is the interface every alert destination must implement.
+type Sink interface {
+	// Name returns a unique, human readable identifier used in logs and
+	// the dropped-alert counters.
+	Name() string
+	// Emit delivers a single alert. It must be safe to call Emit and
+	// Flush/Close concurrently from the dispatcher's goroutine only
+	// (the dispatcher serializes calls per sink).
+	Emit(alert *evtx.GoEvtxMap) error
+	// Flush forces any buffered alert out, if the sink batches.
+	Flush() error
+	// Close releases any resource held by the sink (connections, file
+	// handles, ...).
+	Close() error
+}
+
+// bufferSize is the depth of the per-sink buffered channel the dispatcher
+// uses to decouple a slow sink from the matching loop.
+const bufferSize = 1024
+
+// dispatchedSink wraps a Sink with the goroutine and buffered channel that
+// feed it, plus a counter of alerts dropped on overflow.
+type dispatchedSink struct {
+	sink    Sink
+	tags    map[string]bool
+	in      chan *evtx.GoEvtxMap
+	dropped uint64
+	done    chan struct{}
+}
+
+// Dispatcher fans alerts out to every registered sink, each running on its
+// own goroutine with a bounded buffer so a slow sink cannot stall the
+// others or the caller.
+type Dispatcher struct {
+	sinks []*dispatchedSink
+}
+
+// NewDispatcher creates an empty Dispatcher.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{sinks: make([]*dispatchedSink, 0)}
+}
+
+// Register adds a sink to the dispatcher. tags, if non-empty, restricts the
+// sink to alerts carrying at least one of the given tags; a nil/empty tags
+// set means the sink receives every alert.
+func (d *Dispatcher) Register(s Sink, tags []string) {
+	ds := &dispatchedSink{
+		sink: s,
+		in:   make(chan *evtx.GoEvtxMap, bufferSize),
+		done: make(chan struct{}),
+	}
+	if len(tags) > 0 {
+		ds.tags = make(map[string]bool, len(tags))
+		for _, t := range tags {
+			ds.tags[t] = true
+		}
+	}
+	d.sinks = append(d.sinks, ds)
+
+	go func() {
+		defer close(ds.done)
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case alert, ok := <-ds.in:
+				if !ok {
+					return
+				}
+				if err := s.Emit(alert); err != nil {
+					fmt.Printf("sink %s: failed to emit alert: %s\n", s.Name(), err)
+				}
+			case <-ticker.C:
+				if err := s.Flush(); err != nil {
+					fmt.Printf("sink %s: periodic flush error: %s\n", s.Name(), err)
+				}
+			}
+		}
+	}()
+}
+
+// Emit delivers alert to every registered sink whose tag filter matches,
+// dropping it (and incrementing that sink's drop counter) if the sink's
+// buffer is full.
+func (d *Dispatcher) Emit(alert *evtx.GoEvtxMap, alertTags []string) {
+	for _, ds := range d.sinks {
+		if ds.tags != nil && !anyTagMatches(ds.tags, alertTags) {
+			continue
+		}
+		select {
+		case ds.in <- alert:
+		default:
+			ds.dropped++
+		}
+	}
+}
+
+func anyTagMatches(allowed map[string]bool, tags []string) bool {
+	for _, t := range tags {
+		if allowed[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// Dropped returns the per-sink count of alerts dropped because the sink's
+// buffer was full, keyed by sink name, for the final summary log.
+func (d *Dispatcher) Dropped() map[string]uint64 {
+	out := make(map[string]uint64, len(d.sinks))
+	for _, ds := range d.sinks {
+		out[ds.sink.Name()] = ds.dropped
+	}
+	return out
+}
+
+// Close flushes and closes every registered sink, waiting for their
+// goroutines to drain.
+func (d *Dispatcher) Close() {
+	for _, ds := range d.sinks {
+		close(ds.in)
+		<-ds.done
+		if err := ds.sink.Flush(); err != nil {
+			fmt.Printf("sink %s: flush error: %s\n", ds.sink.Name(), err)
+		}
+		if err := ds.sink.Close(); err != nil {
+			fmt.Printf("sink %s: close error: %s\n", ds.sink.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package sink
+
+import (
+	"fmt"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// StdoutSink prints every alert to stdout as a single line of JSON. It is
+// the default sink and preserves whids' historical behavior.
+type StdoutSink struct{}
+
+// NewStdoutSink creates a StdoutSink.
+func NewStdoutSink() *StdoutSink {
+	return &StdoutSink{}
+}
+
+// Name returns "stdout".
+func (s *StdoutSink) Name() string {
+	return "stdout"
+}
+
+// Emit prints alert as a line of JSON.
+func (s *StdoutSink) Emit(alert *evtx.GoEvtxMap) error {
+	fmt.Println(string(evtx.ToJSON(alert)))
+	return nil
+}
+
+// Flush is a no-op: stdout is never buffered by this sink.
+func (s *StdoutSink) Flush() error {
+	return nil
+}
+
+// Close is a no-op.
+func (s *StdoutSink) Close() error {
+	return nil
+}
@@ -0,0 +1,83 @@
+package sink
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// SyslogSink forwards alerts as RFC5424 formatted messages over UDP, TCP or
+// TLS.
+type SyslogSink struct {
+	network  string // "udp", "tcp" or "tls"
+	addr     string
+	tlsConf  *tls.Config
+	hostname string
+	conn     net.Conn
+}
+
+// NewSyslogSink creates a sink sending RFC5424 messages to addr over
+// network ("udp", "tcp" or "tls"). tlsConf is only used when network is
+// "tls" and may be nil to use the default configuration.
+func NewSyslogSink(network, addr, hostname string, tlsConf *tls.Config) *SyslogSink {
+	return &SyslogSink{network: network, addr: addr, hostname: hostname, tlsConf: tlsConf}
+}
+
+// Name returns "syslog:<network>:<addr>".
+func (s *SyslogSink) Name() string {
+	return fmt.Sprintf("syslog:%s:%s", s.network, s.addr)
+}
+
+// Emit formats alert as RFC5424 and sends it, (re)connecting lazily.
+func (s *SyslogSink) Emit(alert *evtx.GoEvtxMap) error {
+	if err := s.ensureConn(); err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("<14>1 %s %s whids - - - %s\n",
+		time.Now().Format(time.RFC3339), s.hostname, string(evtx.ToJSON(alert)))
+	_, err := s.conn.Write([]byte(msg))
+	if err != nil {
+		// Force a reconnect attempt on the next Emit
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// Flush is a no-op: messages are written synchronously.
+func (s *SyslogSink) Flush() error {
+	return nil
+}
+
+// Close closes the underlying connection, if any.
+func (s *SyslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+func (s *SyslogSink) ensureConn() error {
+	if s.conn != nil {
+		return nil
+	}
+
+	var conn net.Conn
+	var err error
+	switch s.network {
+	case "udp", "tcp":
+		conn, err = net.Dial(s.network, s.addr)
+	case "tls":
+		conn, err = tls.Dial("tcp", s.addr, s.tlsConf)
+	default:
+		return fmt.Errorf("unsupported syslog network: %s", s.network)
+	}
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
@@ -0,0 +1,103 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/0xrawsec/golang-evtx/evtx"
+)
+
+// WebhookSink POSTs alerts as a batched JSON array to a generic HTTP
+// endpoint, retrying failed deliveries with exponential backoff.
+type WebhookSink struct {
+	url        string
+	client     *http.Client
+	batchSize  int
+	maxRetries int
+	backoff    time.Duration
+	batch      [][]byte
+	maxPending int
+	dropped    uint64
+}
+
+// maxPendingFactor bounds how many batchSize's worth of alerts Flush will
+// hold onto across failures before it starts dropping the oldest ones, so a
+// webhook endpoint that's down for a while can't make whids' memory grow
+// without bound.
+const maxPendingFactor = 4
+
+// NewWebhookSink creates a sink posting to url, buffering up to batchSize
+// alerts before flushing, and retrying a failed POST up to maxRetries
+// times with exponential backoff starting at backoff.
+func NewWebhookSink(url string, batchSize, maxRetries int, backoff time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		batchSize:  batchSize,
+		maxRetries: maxRetries,
+		backoff:    backoff,
+		batch:      make([][]byte, 0, batchSize),
+		maxPending: batchSize * maxPendingFactor,
+	}
+}
+
+// Name returns "webhook:<url>".
+func (s *WebhookSink) Name() string {
+	return fmt.Sprintf("webhook:%s", s.url)
+}
+
+// Emit buffers alert, flushing the batch once it reaches batchSize.
+func (s *WebhookSink) Emit(alert *evtx.GoEvtxMap) error {
+	s.batch = append(s.batch, evtx.ToJSON(alert))
+	if len(s.batch) >= s.batchSize {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush POSTs the current batch, if any, retrying with exponential backoff
+// on failure.
+func (s *WebhookSink) Flush() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+
+	body := append([]byte{'['}, bytes.Join(s.batch, []byte(","))...)
+	body = append(body, ']')
+
+	var lastErr error
+	wait := s.backoff
+	for attempt := 0; attempt <= s.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(wait)
+			wait *= 2
+		}
+
+		resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			s.batch = s.batch[:0]
+			return nil
+		}
+		lastErr = fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	if excess := len(s.batch) - s.maxPending; excess > 0 {
+		s.batch = s.batch[excess:]
+		s.dropped += uint64(excess)
+		fmt.Printf("webhook %s: dropped %d alerts after exceeding %d pending, %d dropped total\n", s.url, excess, s.maxPending, s.dropped)
+	}
+
+	return fmt.Errorf("failed to deliver %d alerts after %d retries: %w", len(s.batch), s.maxRetries, lastErr)
+}
+
+// Close flushes any buffered alert.
+func (s *WebhookSink) Close() error {
+	return s.Flush()
+}
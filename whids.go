@@ -1,49 +1,39 @@
 package main
 
 import (
+	"acquisition"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"metrics"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"response"
+	"rulebundle"
+	"sink"
+	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
-	"utils"
 
 	"github.com/0xrawsec/gene/engine"
-	"github.com/0xrawsec/golang-evtx/evtx"
 	"github.com/0xrawsec/golang-utils/args"
 	"github.com/0xrawsec/golang-utils/datastructs"
 	"github.com/0xrawsec/golang-utils/fsutil"
 	"github.com/0xrawsec/golang-utils/fsutil/fswalker"
 	"github.com/0xrawsec/golang-utils/log"
-	"github.com/0xrawsec/golang-win32/win32/wevtapi"
 )
 
 /////////////////////////////////// Main ///////////////////////////////////////
 
-// XMLEventToGoEvtxMap converts an XMLEvent as returned by wevtapi to a GoEvtxMap
-// object that Gene can use
-// TODO: Improve for more perf
-func XMLEventToGoEvtxMap(xe *wevtapi.XMLEvent) (*evtx.GoEvtxMap, error) {
-	ge := make(evtx.GoEvtxMap)
-	bytes, err := json.Marshal(xe.ToJSONEvent())
-	if err != nil {
-		return &ge, err
-	}
-	err = json.Unmarshal(bytes, &ge)
-	if err != nil {
-		return &ge, err
-	}
-	return &ge, nil
-}
-
-/////////////////////////////////// Main ///////////////////////////////////////
-
 const (
 	exitFail    = 1
 	exitSuccess = 0
@@ -60,9 +50,7 @@ const (
 	copyright = "WHIDS Copyright (C) 2017 RawSec SARL (@0xrawsec)"
 	license   = `License Apache 2.0: This program comes with ABSOLUTELY NO WARRANTY.`
 
-	geneRulesRepo = "https://github.com/0xrawsec/gene-rules/archive/master.zip"
-	databaseZip   = "latest-database.zip"
-	databasePath  = "latest-database"
+	databasePath = "latest-database"
 )
 
 var (
@@ -71,18 +59,48 @@ var (
 	versionFlag       bool
 	update            bool
 	rulesPath         string
+	rulesURL          string
+	rulesPubkey       string
 	criticalityThresh int
 	tags              []string
 	names             []string
 	tagsVar           args.ListVar
 	namesVar          args.ListVar
 	windowsChannels   args.ListVar
+	evtxPaths         args.ListVar
+	syslogAddr        string
 	timeout           args.DurationVar
-	channelAliases    = map[string]string{
-		"sysmon":   "Microsoft-Windows-Sysmon/Operational",
-		"security": "Security",
-	}
-	ruleExts = args.ListVar{".gen", ".gene"}
+	ruleExts          = args.ListVar{".gen", ".gene"}
+
+	// Alert sink flags. Each -sink-* flag enables the matching AlertSink
+	// and its -*-tags counterpart sets an optional filter on the names of
+	// the rules an alert matched (empty means "receives everything").
+	sinkFileDir     string
+	sinkFileTags    args.ListVar
+	sinkSyslogAddr  string // network://host:port, e.g. tls://collector:6514
+	sinkSyslogTags  args.ListVar
+	sinkWebhookURL  string
+	sinkWebhookTags args.ListVar
+	sinkESURL       string
+	sinkESIndex     string
+	sinkESTags      args.ListVar
+	sinkKafkaAddrs  args.ListVar
+	sinkKafkaTopic  string
+	sinkKafkaTags   args.ListVar
+
+	// Active response flags.
+	enableResponse     bool
+	responseThresh     int
+	responsePolicyFile string
+	playbookPath       string
+	playbookArgs       args.ListVar
+
+	// Metrics flags.
+	metricsAddr string
+
+	// loadedRules tracks the path and sha256 of every rule file loaded,
+	// for the /rules telemetry endpoint.
+	loadedRules []metrics.RuleInfo
 )
 
 func printInfo(writer io.Writer) {
@@ -91,23 +109,204 @@ func printInfo(writer io.Writer) {
 }
 
 func fmtAliases() string {
-	aliases := make([]string, 0, len(channelAliases))
-	for alias, channel := range channelAliases {
+	aliases := make([]string, 0, len(acquisition.ChannelAliases))
+	for alias, channel := range acquisition.ChannelAliases {
 		aliases = append(aliases, fmt.Sprintf("\t\t%s : %s", alias, channel))
 	}
 	return strings.Join(aliases, "\n")
 }
 
+// loadRuleFile loads a single rule file into e, tracking its sha256 for
+// the /rules telemetry endpoint and counting failures in
+// metrics.RuleLoadErrors.
+func loadRuleFile(e *engine.Engine, path string) {
+	if err := e.Load(path); err != nil {
+		log.Errorf("Error loading %s: %s", path, err)
+		metrics.RuleLoadErrors.Inc()
+		return
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(raw)
+	loadedRules = append(loadedRules, metrics.RuleInfo{Name: path, SHA256: hex.EncodeToString(sum[:])})
+}
+
+// loadRulesPubkey resolves the Ed25519 public key used to verify rule
+// bundles, from the hex string passed to -rules-pubkey. There is no
+// built-in default: whids ships no rule bundle of its own to sign, so
+// trusting any embedded key here would just be a fabricated "official"
+// key operators have no way to audit.
+func loadRulesPubkey(hexKey string) (ed25519.PublicKey, error) {
+	if hexKey == "" {
+		return nil, fmt.Errorf("-rules-pubkey is required when -u is set")
+	}
+	return rulebundle.ParsePublicKeyHex(hexKey)
+}
+
+// buildRegistry turns the -c/-evtx/-syslog flags into a configured
+// acquisition.Registry. Sources are combined: each -c value spawns a
+// wevtapi source, each -evtx value spawns an EVTX replay source, and
+// -syslog (if set) spawns a single syslog listener.
+func buildRegistry() (*acquisition.Registry, error) {
+	reg := acquisition.NewRegistry()
+
+	for _, c := range []string(windowsChannels) {
+		src := acquisition.NewWevtapiSource(c)
+		if err := src.Configure(map[string]interface{}{"channel": c}); err != nil {
+			return nil, err
+		}
+		reg.Add(src)
+	}
+
+	for _, p := range []string(evtxPaths) {
+		src := acquisition.NewEvtxFileSource(p)
+		if err := src.Configure(map[string]interface{}{"path": p}); err != nil {
+			return nil, err
+		}
+		reg.Add(src)
+	}
+
+	if syslogAddr != "" {
+		proto, addr := "udp", syslogAddr
+		if idx := strings.Index(syslogAddr, "://"); idx != -1 {
+			proto, addr = syslogAddr[:idx], syslogAddr[idx+3:]
+		}
+		src := acquisition.NewSyslogSource(proto, addr)
+		if err := src.Configure(map[string]interface{}{"proto": proto, "addr": addr}); err != nil {
+			return nil, err
+		}
+		reg.Add(src)
+	}
+
+	if len(reg.Sources()) == 0 {
+		return nil, fmt.Errorf("no acquisition source configured: use -c, -evtx or -syslog")
+	}
+
+	return reg, nil
+}
+
+// buildDispatcher wires up the alert sinks enabled from the -sink-* flags.
+// stdout is always registered first so the historical default behavior is
+// preserved when no other sink is configured.
+func buildDispatcher() (*sink.Dispatcher, error) {
+	d := sink.NewDispatcher()
+	d.Register(sink.NewStdoutSink(), nil)
+
+	if sinkFileDir != "" {
+		d.Register(sink.NewFileSink(sinkFileDir, "whids-alerts", 100*1024*1024, 24*time.Hour), []string(sinkFileTags))
+	}
+
+	if sinkSyslogAddr != "" {
+		network, addr := "udp", sinkSyslogAddr
+		if idx := strings.Index(sinkSyslogAddr, "://"); idx != -1 {
+			network, addr = sinkSyslogAddr[:idx], sinkSyslogAddr[idx+3:]
+		}
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "whids"
+		}
+		d.Register(sink.NewSyslogSink(network, addr, hostname, nil), []string(sinkSyslogTags))
+	}
+
+	if sinkWebhookURL != "" {
+		d.Register(sink.NewWebhookSink(sinkWebhookURL, 50, 5, time.Second), []string(sinkWebhookTags))
+	}
+
+	if sinkESURL != "" {
+		if sinkESIndex == "" {
+			return nil, fmt.Errorf("-sink-es-index is required when -sink-es is set")
+		}
+		d.Register(sink.NewElasticsearchSink(sinkESURL, sinkESIndex, 50), []string(sinkESTags))
+	}
+
+	if len(sinkKafkaAddrs) > 0 {
+		if sinkKafkaTopic == "" {
+			return nil, fmt.Errorf("-sink-kafka-topic is required when -sink-kafka is set")
+		}
+		kafka, err := sink.NewKafkaSink([]string(sinkKafkaAddrs), sinkKafkaTopic)
+		if err != nil {
+			return nil, err
+		}
+		d.Register(kafka, []string(sinkKafkaTags))
+	}
+
+	return d, nil
+}
+
+// responsePolicy is the on-disk format of -response-policy: a plain
+// mapping of rule name to the reactions its `response:` annotation
+// requests, extracted by the rule author when the rule is written.
+type responsePolicy map[string][]string
+
+// buildResponder wires up the active response subsystem: the reactions it
+// knows how to run and the rule -> reaction policy loaded from
+// -response-policy, gated by --enable-response.
+func buildResponder(sinks *sink.Dispatcher) (*response.Dispatcher, error) {
+	d := response.NewDispatcher(enableResponse, responseThresh, sinks)
+
+	d.Register(response.KillProcess{})
+	d.Register(response.SuspendTree{})
+	d.Register(response.IsolateHost{})
+	d.Register(response.Quarantine{})
+	if playbookPath != "" {
+		d.Register(response.NewPlaybook(playbookPath, []string(playbookArgs)...))
+	}
+
+	if responsePolicyFile != "" {
+		raw, err := ioutil.ReadFile(responsePolicyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read -response-policy: %w", err)
+		}
+		var policy responsePolicy
+		if err := json.Unmarshal(raw, &policy); err != nil {
+			return nil, fmt.Errorf("failed to parse -response-policy: %w", err)
+		}
+		d.SetPolicy(policy)
+	} else if enableResponse {
+		log.Infof("--enable-response is set but -response-policy is empty: no rule will trigger a reaction")
+	}
+
+	return d, nil
+}
+
 func main() {
 	flag.Var(&windowsChannels, "c", fmt.Sprintf("Windows channels to monitor or their aliases.\n\tAvailable aliases:\n%s\n", fmtAliases()))
+	flag.Var(&evtxPaths, "evtx", "Offline EVTX file or directory to replay instead of (or in addition to) live channels")
+	flag.StringVar(&syslogAddr, "syslog", syslogAddr, "Listen for forwarded Windows events on a syslog socket (format: [udp|tcp]://host:port)")
 	flag.Var(&timeout, "timeout", "Stop working after timeout (format: 1s, 1m, 1h, 1d ...)")
 	flag.BoolVar(&trace, "trace", trace, "Tells the engine to use the trace function of the rules")
 	flag.BoolVar(&debug, "d", debug, "Enable debugging messages")
 	flag.BoolVar(&versionFlag, "v", versionFlag, "Print version information and exit")
-	flag.BoolVar(&update, "u", update, fmt.Sprintf("Update gene database and use it in addition to the other rule paths (Repo: %s)", geneRulesRepo))
+	flag.BoolVar(&update, "u", update, "Update gene rule bundle and load rules from it instead of -r (requires -rules-url and -rules-pubkey)")
+	flag.StringVar(&rulesURL, "rules-url", rulesURL, "URL of the signed rule bundle to pull on -u; required, there is no built-in default")
+	flag.StringVar(&rulesPubkey, "rules-pubkey", rulesPubkey, "Hex encoded Ed25519 public key used to verify rule bundles; required, there is no built-in default")
 	flag.StringVar(&rulesPath, "r", rulesPath, "Rule file or directory")
 	flag.IntVar(&criticalityThresh, "t", criticalityThresh, "Criticality treshold. Prints only if criticality above threshold")
 
+	flag.StringVar(&sinkFileDir, "sink-file", sinkFileDir, "Also write alerts as rotating JSON-lines files in this directory")
+	flag.Var(&sinkFileTags, "sink-file-tags", "Restrict the file sink to alerts matching one of these rule names")
+	flag.StringVar(&sinkSyslogAddr, "sink-syslog", sinkSyslogAddr, "Also forward alerts to a syslog collector (format: [udp|tcp|tls]://host:port)")
+	flag.Var(&sinkSyslogTags, "sink-syslog-tags", "Restrict the syslog sink to alerts matching one of these rule names")
+	flag.StringVar(&sinkWebhookURL, "sink-webhook", sinkWebhookURL, "Also POST alerts, batched, to this HTTP webhook URL")
+	flag.Var(&sinkWebhookTags, "sink-webhook-tags", "Restrict the webhook sink to alerts matching one of these rule names")
+	flag.StringVar(&sinkESURL, "sink-es", sinkESURL, "Also bulk-index alerts into this Elasticsearch base URL")
+	flag.StringVar(&sinkESIndex, "sink-es-index", sinkESIndex, "Elasticsearch index to bulk-index alerts into")
+	flag.Var(&sinkESTags, "sink-es-tags", "Restrict the Elasticsearch sink to alerts matching one of these rule names")
+	flag.Var(&sinkKafkaAddrs, "sink-kafka", "Also publish alerts to these Kafka brokers")
+	flag.StringVar(&sinkKafkaTopic, "sink-kafka-topic", sinkKafkaTopic, "Kafka topic to publish alerts to")
+	flag.Var(&sinkKafkaTags, "sink-kafka-tags", "Restrict the Kafka sink to alerts matching one of these rule names")
+
+	flag.BoolVar(&enableResponse, "enable-response", enableResponse, "Enable active response: execute reactions for rules matching -response-policy")
+	flag.IntVar(&responseThresh, "response-threshold", responseThresh, "Minimum criticality (inclusive) a match needs for reactions to run")
+	flag.StringVar(&responsePolicyFile, "response-policy", responsePolicyFile, "JSON file mapping rule name to the list of reactions its response: annotation requests")
+	flag.StringVar(&playbookPath, "response-playbook", playbookPath, "PowerShell script or executable run as the \"playbook:<path>\" reaction, fed the matched event JSON on stdin")
+	flag.Var(&playbookArgs, "response-playbook-arg", "Extra argument passed to the playbook reaction (repeatable)")
+
+	flag.StringVar(&metricsAddr, "metrics-addr", metricsAddr, "Listen address (host:port) for the Prometheus /metrics, /healthz and /rules endpoints")
+
 	flag.Usage = func() {
 		printInfo(os.Stderr)
 		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS]\n", filepath.Base(os.Args[0]))
@@ -130,15 +329,19 @@ func main() {
 
 	// Update Database
 	if update {
-		log.Infof("Downloading rules from: %s", geneRulesRepo)
-		client := &http.Client{}
-		err := utils.HTTPGet(client, geneRulesRepo, databaseZip)
-		if err != nil {
-			log.LogErrorAndExit(fmt.Errorf("Could not download latest gene-rules: %s", err), exitFail)
+		if rulesURL == "" {
+			log.LogErrorAndExit(fmt.Errorf("-rules-url is required when -u is set"), exitFail)
 		}
-		err = utils.Unzip(databaseZip, databasePath)
+
+		pubKey, err := loadRulesPubkey(rulesPubkey)
 		if err != nil {
-			log.LogErrorAndExit(fmt.Errorf("Could not unzip latest gene-rules: %s", err), exitFail)
+			log.LogErrorAndExit(err, exitFail)
+		}
+
+		log.Infof("Updating rule bundle from: %s", rulesURL)
+		updater := rulebundle.NewUpdater(&http.Client{}, rulesURL, pubKey, databasePath)
+		if err := updater.Update(); err != nil {
+			log.LogErrorAndExit(fmt.Errorf("Could not update gene rule bundle: %s", err), exitFail)
 		}
 		rulesPath = databasePath
 	}
@@ -174,10 +377,7 @@ func main() {
 	// Handle both rules argument as file or directory
 	switch {
 	case fsutil.IsFile(realPath):
-		err := e.Load(realPath)
-		if err != nil {
-			log.Error(err)
-		}
+		loadRuleFile(e, realPath)
 	case fsutil.IsDir(realPath):
 		for wi := range fswalker.Walk(realPath) {
 			for _, fi := range wi.Files {
@@ -186,10 +386,7 @@ func main() {
 				log.Debug(ext)
 				// Check if the file extension is in the list of valid rule extension
 				if setRuleExts.Contains(ext) {
-					err := e.Load(rulefile)
-					if err != nil {
-						log.Errorf("Error loading %s: %s", rulefile, err)
-					}
+					loadRuleFile(e, rulefile)
 				}
 			}
 		}
@@ -198,16 +395,43 @@ func main() {
 	}
 	log.Infof("Loaded %d rules", e.Count())
 
+	// Start the Prometheus metrics endpoint if requested
+	if metricsAddr != "" {
+		server := metrics.NewServer(metricsAddr, func() []metrics.RuleInfo { return loadedRules })
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Errorf("metrics server stopped: %s", err)
+			}
+		}()
+		log.Infof("Metrics endpoint listening on %s", metricsAddr)
+	}
+
+	// Build the acquisition sources (live channels, EVTX replay, syslog)
+	registry, err := buildRegistry()
+	if err != nil {
+		log.LogErrorAndExit(err, exitFail)
+	}
+
+	// Build the alert sinks (stdout plus whatever -sink-* flags enable)
+	dispatcher, err := buildDispatcher()
+	if err != nil {
+		log.LogErrorAndExit(err, exitFail)
+	}
+	defer dispatcher.Close()
+
+	// Build the active response subsystem (no-op unless --enable-response)
+	responder, err := buildResponder(dispatcher)
+	if err != nil {
+		log.LogErrorAndExit(err, exitFail)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
 	// Register a timeout if specified in Command line
-	signals := make(chan bool)
-	eventCnt, alertsCnt := 0, 0
-	start := time.Now()
 	if timeout > 0 {
 		go func() {
 			time.Sleep(time.Duration(timeout))
-			for _ = range []string(windowsChannels) {
-				signals <- true
-			}
+			cancel()
 		}()
 	}
 
@@ -216,47 +440,68 @@ func main() {
 	signal.Notify(osSignals, os.Interrupt)
 	go func() {
 		<-osSignals
-		for _ = range []string(windowsChannels) {
-			signals <- true
+		cancel()
+	}()
+
+	var eventCnt, alertsCnt int64
+	eventsBySource := make(map[string]*int64)
+	alertsBySource := make(map[string]*int64)
+	for _, src := range registry.Sources() {
+		eventsBySource[src.Name()] = new(int64)
+		alertsBySource[src.Name()] = new(int64)
+		log.Infof("Starting acquisition source %s (%s)", src.Name(), src.Mode())
+		metrics.AcquisitionUp.WithLabelValues(src.Name()).Set(1)
+	}
+
+	start := time.Now()
+	events, acqErrs := registry.RunAllTagged(ctx)
+
+	go func() {
+		for err := range acqErrs {
+			log.Error(err)
 		}
 	}()
 
-	// Loop starting the monitoring of the various channels
-	waitGr := sync.WaitGroup{}
-	channels := []string(windowsChannels)
-	for i := range channels {
-		winChan := channels[i]
-		waitGr.Add(1)
-		// New go routine per channel
-		go func() {
-			defer waitGr.Done()
-			// Try to find an alias to the channel
-			if c, ok := channelAliases[strings.ToLower(winChan)]; ok {
-				winChan = c
-			}
-			log.Infof("Listening on Windows channel: %s", winChan)
-			ec := wevtapi.GetAllEventsFromChannel(winChan, wevtapi.EvtSubscribeToFutureEvents, signals)
-			for xe := range ec {
-				event, err := XMLEventToGoEvtxMap(xe)
-				if err != nil {
-					log.Errorf("Failed to convert event: %s", err)
-					log.Debugf("Error data: %v", xe)
-				}
-				if n, crit := e.Match(event); len(n) > 0 {
-					if crit >= criticalityThresh {
-						fmt.Println(string(evtx.ToJSON(event)))
-						alertsCnt++
-					}
+	for tagged := range events {
+		matchStart := time.Now()
+		n, crit := e.Match(tagged.Event)
+		metrics.MatchDuration.Observe(time.Since(matchStart).Seconds())
+
+		if len(n) > 0 {
+			if crit >= criticalityThresh {
+				dispatcher.Emit(tagged.Event, n)
+				atomic.AddInt64(&alertsCnt, 1)
+				atomic.AddInt64(alertsBySource[tagged.Source], 1)
+				for _, rule := range n {
+					metrics.AlertsTotal.WithLabelValues(rule, strconv.Itoa(crit)).Inc()
 				}
-				eventCnt++
 			}
-		}()
+			responder.Dispatch(tagged.Event, n, crit)
+		}
+		atomic.AddInt64(&eventCnt, 1)
+		atomic.AddInt64(eventsBySource[tagged.Source], 1)
+		metrics.EventsTotal.WithLabelValues(tagged.Source).Inc()
+	}
+
+	for _, src := range registry.Sources() {
+		metrics.AcquisitionUp.WithLabelValues(src.Name()).Set(0)
 	}
-	waitGr.Wait()
 
 	stop := time.Now()
 	log.Infof("Count Event Scanned: %d", eventCnt)
-	log.Infof("Average Event Rate: %.2f EPS", float64(eventCnt)/(stop.Sub(start).Seconds()))
+	log.Infof("Average Event Rate: %.2f EPS", float64(eventCnt)/(stop.Sub(start).Seconds()))
 	log.Infof("Alerts Reported: %d", alertsCnt)
 	log.Infof("Count Rules Used (loaded + generated): %d", e.Count())
+	elapsed := stop.Sub(start).Seconds()
+	for name, cnt := range eventsBySource {
+		n := atomic.LoadInt64(cnt)
+		a := atomic.LoadInt64(alertsBySource[name])
+		log.Infof("Source %s: %d events (%.2f EPS), %d alerts", name, n, float64(n)/elapsed, a)
+	}
+	for name, dropped := range dispatcher.Dropped() {
+		metrics.SinkDropped.WithLabelValues(name).Add(float64(dropped))
+		if dropped > 0 {
+			log.Infof("Sink %s: %d alerts dropped", name, dropped)
+		}
+	}
 }